@@ -1,18 +1,170 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"sort"
 	"strings"
 
+	"github.com/smira/aptly"
 	"github.com/smira/aptly/deb"
+	"github.com/smira/aptly/query"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
 
+// pullPlanArch is the per-architecture portion of a machine-readable pull plan, meant to be
+// diffed between runs or gated on in CI.
+type pullPlanArch struct {
+	Architecture            string   `json:"architecture"`
+	SatisfiedDependencies   []string `json:"satisfiedDependencies"`
+	UnsatisfiedDependencies []string `json:"unsatisfiedDependencies"`
+	Added                   []string `json:"added"`
+	Removed                 []string `json:"removed"`
+}
+
+// pullPlan is the top-level document written out by -output=json / -plan-file.
+type pullPlan struct {
+	Name          string         `json:"name"`
+	Destination   string         `json:"destination"`
+	Sources       []string       `json:"sources"`
+	DryRun        bool           `json:"dryRun"`
+	Architectures []pullPlanArch `json:"architectures"`
+	Refs          []string       `json:"refs"`
+}
+
+// snapshotSourceList collects one or more -source flags (each possibly a comma-separated
+// list) into a single priority-ordered list of snapshot names, first one being the highest
+// priority.
+type snapshotSourceList struct {
+	names []string
+}
+
+func (s *snapshotSourceList) String() string {
+	return strings.Join(s.names, ",")
+}
+
+func (s *snapshotSourceList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			s.names = append(s.names, part)
+		}
+	}
+	return nil
+}
+
+// pullSilentProgress wraps an aptly.Progress, discarding the human-readable Printf/ColoredPrintf
+// output while still delegating everything else (e.g. progress bars opened by library calls such
+// as NewPackageListFromRefList). Used under -output=json so the only thing written to stdout is
+// the JSON pull plan itself.
+type pullSilentProgress struct {
+	aptly.Progress
+}
+
+func (pullSilentProgress) Printf(format string, args ...interface{})        {}
+func (pullSilentProgress) ColoredPrintf(format string, args ...interface{}) {}
+
+// pullProgress returns the progress to use for a pull: the real one, or a silent wrapper around
+// it when the output is going to be consumed as JSON and can't be interleaved with log lines.
+func pullProgress(jsonOutput bool) aptly.Progress {
+	if jsonOutput {
+		return pullSilentProgress{context.Progress()}
+	}
+
+	return context.Progress()
+}
+
+// validatePullModes rejects flag combinations that aren't (yet) supported: -filter-with-deps
+// resolves its closure from the plain -package-name/dependency arguments, which aren't
+// populated when -filter-query is in effect, so combining the two would silently turn into a
+// no-op pull instead of doing anything useful.
+func validatePullModes(queryMode, filterWithDeps bool) error {
+	if queryMode && filterWithDeps {
+		return fmt.Errorf("unable to pull: -filter-query can't be combined with -filter-with-deps")
+	}
+
+	return nil
+}
+
+// pullSearch looks for a package satisfying dep, trying sourcePackageLists in priority order
+// and falling back to lower-priority sources when higher-priority ones don't have a match.
+func pullSearch(sourcePackageLists []*deb.PackageList, dep deb.Dependency, allMatches bool) []*deb.Package {
+	for _, spl := range sourcePackageLists {
+		if searchResults := spl.Search(dep, allMatches); searchResults != nil {
+			return searchResults
+		}
+	}
+
+	return nil
+}
+
+// pullProcessMatches removes conflicting packages from packageList and adds searchResults to it,
+// returning the (possibly extended) list of dependencies still to be resolved for arch
+func pullProcessMatches(searchResults []*deb.Package, packageList *deb.PackageList, dependencies []deb.Dependency,
+	arch string, noRemove, allMatches, noDeps bool, plan *pullPlanArch, progress aptly.Progress) ([]deb.Dependency, error) {
+	if len(searchResults) == 0 {
+		return dependencies, nil
+	}
+
+	if !noRemove {
+		// Remove all packages with the same name and architecture
+		for _, pkg := range searchResults {
+			for pS := packageList.Search(deb.Dependency{Architecture: pkg.Architecture, Pkg: pkg.Name}, allMatches); pS != nil; {
+				for _, p := range pS {
+					packageList.Remove(p)
+					progress.ColoredPrintf("@r[-]@| %s removed", p)
+					plan.Removed = append(plan.Removed, p.String())
+				}
+				pS = packageList.Search(deb.Dependency{Architecture: pkg.Architecture, Pkg: pkg.Name}, allMatches)
+			}
+		}
+	}
+
+	// Add new discovered packages
+	for _, pkg := range searchResults {
+		packageList.Add(pkg)
+		progress.ColoredPrintf("@g[+]@| %s added", pkg)
+		plan.Added = append(plan.Added, pkg.String())
+	}
+
+	if noDeps {
+		return dependencies, nil
+	}
+
+	// Find missing dependencies for the packages just added
+	pL := deb.NewPackageList()
+	for _, pkg := range searchResults {
+		pL.Add(pkg)
+
+		missing, err := pL.VerifyDependencies(context.DependencyOptions(), []string{arch}, packageList, nil)
+		if err != nil {
+			progress.ColoredPrintf("@y[!]@| @!Error while verifying dependencies for pkg %s: %s@|", pkg, err)
+		}
+
+		// Append missing dependencies to the list of dependencies to satisfy
+		for _, misDep := range missing {
+			found := false
+			for _, d := range dependencies {
+				if d == misDep {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				dependencies = append(dependencies, misDep)
+			}
+		}
+	}
+
+	return dependencies, nil
+}
+
 func aptlySnapshotPull(cmd *commander.Command, args []string) error {
 	var err error
-	if len(args) < 4 {
+	if len(args) < 3 {
 		cmd.Usage()
 		return commander.ErrCommandError
 	}
@@ -20,6 +172,23 @@ func aptlySnapshotPull(cmd *commander.Command, args []string) error {
 	noDeps := context.flags.Lookup("no-deps").Value.Get().(bool)
 	noRemove := context.flags.Lookup("no-remove").Value.Get().(bool)
 	allMatches := context.flags.Lookup("all-matches").Value.Get().(bool)
+	queryMode := context.flags.Lookup("filter-query").Value.Get().(bool)
+	filterWithDeps := context.flags.Lookup("filter-with-deps").Value.Get().(bool)
+	outputFormat := context.flags.Lookup("output").Value.Get().(string)
+	planFile := context.flags.Lookup("plan-file").Value.Get().(string)
+
+	if err = validatePullModes(queryMode, filterWithDeps); err != nil {
+		return err
+	}
+
+	// Under -output=json, stdout must be clean, parseable JSON, so progress/log output below is
+	// routed through a silent wrapper instead of context.Progress() directly.
+	progress := pullProgress(outputFormat == "json")
+
+	sourceNames := context.flags.Lookup("source").Value.(*snapshotSourceList).names
+	if len(sourceNames) == 0 {
+		return fmt.Errorf("unable to pull: at least one -source snapshot must be specified")
+	}
 
 	// Load <name> snapshot
 	snapshot, err := context.CollectionFactory().SnapshotCollection().ByName(args[0])
@@ -32,35 +201,48 @@ func aptlySnapshotPull(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to pull: %s", err)
 	}
 
-	// Load <source> snapshot
-	source, err := context.CollectionFactory().SnapshotCollection().ByName(args[1])
-	if err != nil {
-		return fmt.Errorf("unable to pull: %s", err)
-	}
+	// Load <source> snapshots, in priority order
+	sources := make([]*deb.Snapshot, len(sourceNames))
+	for i, name := range sourceNames {
+		sources[i], err = context.CollectionFactory().SnapshotCollection().ByName(name)
+		if err != nil {
+			return fmt.Errorf("unable to pull: %s", err)
+		}
 
-	err = context.CollectionFactory().SnapshotCollection().LoadComplete(source)
-	if err != nil {
-		return fmt.Errorf("unable to pull: %s", err)
+		err = context.CollectionFactory().SnapshotCollection().LoadComplete(sources[i])
+		if err != nil {
+			return fmt.Errorf("unable to pull: %s", err)
+		}
 	}
 
-	context.Progress().Printf("Dependencies would be pulled into snapshot:\n    %s\nfrom snapshot:\n    %s\nand result would be saved as new snapshot %s.\n",
-		snapshot, source, args[2])
+	progress.Printf("Dependencies would be pulled into snapshot:\n    %s\nfrom snapshots (in priority order):\n    %s\nand result would be saved as new snapshot %s.\n",
+		snapshot, strings.Join(sourceNames, ", "), args[1])
 
 	// Convert snapshot to package list
-	context.Progress().Printf("Loading packages (%d)...\n", snapshot.RefList().Len()+source.RefList().Len())
-	packageList, err := deb.NewPackageListFromRefList(snapshot.RefList(), context.CollectionFactory().PackageCollection(), context.Progress())
-	if err != nil {
-		return fmt.Errorf("unable to load packages: %s", err)
+	totalRefs := snapshot.RefList().Len()
+	for _, source := range sources {
+		totalRefs += source.RefList().Len()
 	}
 
-	sourcePackageList, err := deb.NewPackageListFromRefList(source.RefList(), context.CollectionFactory().PackageCollection(), context.Progress())
+	progress.Printf("Loading packages (%d)...\n", totalRefs)
+	packageList, err := deb.NewPackageListFromRefList(snapshot.RefList(), context.CollectionFactory().PackageCollection(), progress)
 	if err != nil {
 		return fmt.Errorf("unable to load packages: %s", err)
 	}
 
-	context.Progress().Printf("Building indexes...\n")
+	sourcePackageLists := make([]*deb.PackageList, len(sources))
+	for i, source := range sources {
+		sourcePackageLists[i], err = deb.NewPackageListFromRefList(source.RefList(), context.CollectionFactory().PackageCollection(), progress)
+		if err != nil {
+			return fmt.Errorf("unable to load packages: %s", err)
+		}
+	}
+
+	progress.Printf("Building indexes...\n")
 	packageList.PrepareIndex()
-	sourcePackageList.PrepareIndex()
+	for _, spl := range sourcePackageLists {
+		spl.PrepareIndex()
+	}
 
 	// Calculate architectures
 	var architecturesList []string
@@ -77,16 +259,31 @@ func aptlySnapshotPull(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to determine list of architectures, please specify explicitly")
 	}
 
-	// Initial dependencies out of arguments
-	initialDependencies := make([]deb.Dependency, len(args)-3)
-	for i, arg := range args[3:] {
-		initialDependencies[i], err = deb.ParseDependency(arg)
-		if err != nil {
-			return fmt.Errorf("unable to parse argument: %s", err)
+	// Initial dependencies/queries out of arguments
+	var initialDependencies []deb.Dependency
+	var initialQueries []deb.PackageQuery
+
+	if queryMode {
+		initialQueries = make([]deb.PackageQuery, len(args)-2)
+		for i, arg := range args[2:] {
+			initialQueries[i], err = query.Parse(arg)
+			if err != nil {
+				return fmt.Errorf("unable to parse argument: %s", err)
+			}
+		}
+	} else {
+		initialDependencies = make([]deb.Dependency, len(args)-2)
+		for i, arg := range args[2:] {
+			initialDependencies[i], err = deb.ParseDependency(arg)
+			if err != nil {
+				return fmt.Errorf("unable to parse argument: %s", err)
+			}
 		}
 	}
 
 	// Perform pull
+	archPlans := make([]pullPlanArch, 0, len(architecturesList))
+
 	for _, arch := range architecturesList {
 		dependencies := make([]deb.Dependency, len(initialDependencies), 2*len(initialDependencies))
 		for i := range dependencies {
@@ -94,82 +291,193 @@ func aptlySnapshotPull(cmd *commander.Command, args []string) error {
 			dependencies[i].Architecture = arch
 		}
 
-		// Go over list of initial dependencies + list of dependencies found
-		for i := 0; i < len(dependencies); i++ {
-			dep := dependencies[i]
+		plan := &pullPlanArch{Architecture: arch}
+
+		if filterWithDeps {
+			// Resolve the transitive dependency closure over the source snapshots in one pass per
+			// source, instead of chasing missing dependencies one at a time below. Scales much
+			// better for large pulls and honors the same -dep-follow-* flags as mirror create.
+			// Fallback is per-dependency, not per-source: each source only closes over the subset
+			// of the still-unresolved dependencies it actually carries, and whatever remains is
+			// handed down to the next, lower-priority source.
+			remaining := append([]deb.Dependency(nil), dependencies...)
+			closure := deb.NewPackageList()
+
+			for _, spl := range sourcePackageLists {
+				if len(remaining) == 0 {
+					break
+				}
 
-			// Search for package that can satisfy dependencies
-			searchResults := sourcePackageList.Search(dep, allMatches)
-			if searchResults == nil {
-				context.Progress().ColoredPrintf("@y[!]@| @!Dependency %s can't be satisfied with source %s@|", &dep, source)
-				continue
-			}
+				var here, stillUnresolved []deb.Dependency
+				for _, dep := range remaining {
+					if spl.Search(dep, allMatches) != nil {
+						here = append(here, dep)
+					} else {
+						stillUnresolved = append(stillUnresolved, dep)
+					}
+				}
 
-			if !noRemove {
-				// Remove all packages with the same name and architecture
-				for _, pkg := range searchResults {
-					for pS := packageList.Search(deb.Dependency{Architecture: pkg.Architecture, Pkg: pkg.Name}, allMatches); pS != nil; {
-						for _, p := range pS {
-							packageList.Remove(p)
-							context.Progress().ColoredPrintf("@r[-]@| %s removed", p)
-						}
-						pS = packageList.Search(deb.Dependency{Architecture: pkg.Architecture, Pkg: pkg.Name}, allMatches)
+				if len(here) > 0 {
+					hereQueries := make([]deb.PackageQuery, len(here))
+					for j := range here {
+						hereQueries[j] = &deb.DependencyQuery{Dep: here[j]}
+					}
+
+					var sourceClosure *deb.PackageList
+					sourceClosure, err = spl.FilterWithProgress(hereQueries, !noDeps, packageList, context.DependencyOptions(), []string{arch}, progress)
+					if err != nil {
+						return fmt.Errorf("unable to resolve dependency closure: %s", err)
+					}
+
+					for _, pkg := range sourceClosure.Packages() {
+						closure.Add(pkg)
+					}
+
+					for _, dep := range here {
+						plan.SatisfiedDependencies = append(plan.SatisfiedDependencies, dep.String())
 					}
 				}
+
+				remaining = stillUnresolved
 			}
 
-			// Add new discovered package
-			for _, pkg := range searchResults {
-				packageList.Add(pkg)
-				context.Progress().ColoredPrintf("@g[+]@| %s added", pkg)
+			for _, dep := range remaining {
+				progress.ColoredPrintf("@y[!]@| @!Dependency %s can't be satisfied with sources %s@|", &dep, strings.Join(sourceNames, ", "))
+				plan.UnsatisfiedDependencies = append(plan.UnsatisfiedDependencies, dep.String())
 			}
 
-			if noDeps {
-				continue
+			if _, err = pullProcessMatches(closure.Packages(), packageList, nil, arch, noRemove, allMatches, true, plan, progress); err != nil {
+				return fmt.Errorf("unable to apply dependency closure: %s", err)
 			}
 
-			// Find missing dependencies for single added package
-			pL := deb.NewPackageList()
-			for _, pkg := range searchResults {
-				pL.Add(pkg)
+			// FilterWithProgress only resolves a dependency's transitive closure against the single
+			// source it was asked to search. If a package several levels down only exists in a
+			// lower-priority source, it's left out of the closure rather than reported -- verify the
+			// closure here and hand anything still missing to the regular per-dependency loop below,
+			// which already retries across sources and records satisfied/unsatisfied per item.
+			closurePackages := deb.NewPackageList()
+			for _, pkg := range closure.Packages() {
+				closurePackages.Add(pkg)
+			}
 
-				var missing []deb.Dependency
-				missing, err = pL.VerifyDependencies(context.DependencyOptions(), []string{arch}, packageList, nil)
+			var transitivelyMissing []deb.Dependency
+			if !noDeps {
+				transitivelyMissing, err = closurePackages.VerifyDependencies(context.DependencyOptions(), []string{arch}, packageList, nil)
 				if err != nil {
-					context.Progress().ColoredPrintf("@y[!]@| @!Error while verifying dependencies for pkg %s: %s@|", pkg, err)
+					progress.ColoredPrintf("@y[!]@| @!Error while verifying dependency closure: %s@|", err)
 				}
+			}
 
-				// Append missing dependencies to the list of dependencies to satisfy
-				for _, misDep := range missing {
-					found := false
-					for _, d := range dependencies {
-						if d == misDep {
-							found = true
-							break
-						}
+			dependencies = transitivelyMissing
+		} else if queryMode {
+			// Fallback is per-query, not per-source: a selector is resolved from the first
+			// source that has a match for it, independently of whether other selectors were
+			// already satisfied by a higher-priority source.
+			matched := deb.NewPackageList()
+			for i, q := range initialQueries {
+				queryArg := args[2+i]
+				satisfied := false
+
+				for _, spl := range sourcePackageLists {
+					var m *deb.PackageList
+					m, err = spl.Filter([]deb.PackageQuery{q}, false, packageList, context.DependencyOptions(), []string{arch})
+					if err != nil {
+						return fmt.Errorf("unable to filter packages: %s", err)
 					}
 
-					if !found {
-						dependencies = append(dependencies, misDep)
+					if m.Len() > 0 {
+						for _, pkg := range m.Packages() {
+							matched.Add(pkg)
+						}
+						satisfied = true
+						break
 					}
 				}
+
+				if satisfied {
+					plan.SatisfiedDependencies = append(plan.SatisfiedDependencies, queryArg)
+				} else {
+					progress.ColoredPrintf("@y[!]@| @!Query %q can't be satisfied with sources %s@|", queryArg, strings.Join(sourceNames, ", "))
+					plan.UnsatisfiedDependencies = append(plan.UnsatisfiedDependencies, queryArg)
+				}
+			}
+
+			dependencies, err = pullProcessMatches(matched.Packages(), packageList, dependencies, arch, noRemove, allMatches, noDeps, plan, progress)
+			if err != nil {
+				return fmt.Errorf("unable to process query results: %s", err)
+			}
+		}
+
+		// Go over list of dependencies found (initial + discovered while resolving)
+		for i := 0; i < len(dependencies); i++ {
+			dep := dependencies[i]
+
+			// Search for package that can satisfy dependency, preferring higher-priority sources
+			searchResults := pullSearch(sourcePackageLists, dep, allMatches)
+			if searchResults == nil {
+				progress.ColoredPrintf("@y[!]@| @!Dependency %s can't be satisfied with sources %s@|", &dep, strings.Join(sourceNames, ", "))
+				plan.UnsatisfiedDependencies = append(plan.UnsatisfiedDependencies, dep.String())
+				continue
 			}
+
+			plan.SatisfiedDependencies = append(plan.SatisfiedDependencies, dep.String())
+
+			dependencies, err = pullProcessMatches(searchResults, packageList, dependencies, arch, noRemove, allMatches, noDeps, plan, progress)
+			if err != nil {
+				return fmt.Errorf("unable to process matches: %s", err)
+			}
+		}
+
+		archPlans = append(archPlans, *plan)
+	}
+
+	if outputFormat == "json" || planFile != "" {
+		refs := make([]string, 0, packageList.Len())
+		for _, pkg := range packageList.Packages() {
+			refs = append(refs, pkg.String())
+		}
+
+		plan := pullPlan{
+			Name:          args[0],
+			Destination:   args[1],
+			Sources:       sourceNames,
+			DryRun:        context.flags.Lookup("dry-run").Value.Get().(bool),
+			Architectures: archPlans,
+			Refs:          refs,
+		}
+
+		planJSON, jsonErr := json.MarshalIndent(plan, "", "  ")
+		if jsonErr != nil {
+			return fmt.Errorf("unable to build pull plan: %s", jsonErr)
+		}
+
+		if planFile != "" {
+			if jsonErr = ioutil.WriteFile(planFile, planJSON, 0644); jsonErr != nil {
+				return fmt.Errorf("unable to write pull plan: %s", jsonErr)
+			}
+		}
+
+		if outputFormat == "json" {
+			// The plan itself is the one thing that must reach stdout under -output=json, so print
+			// it via the real progress, bypassing the silent wrapper used for everything else above.
+			context.Progress().Printf("%s\n", planJSON)
 		}
 	}
 
 	if context.flags.Lookup("dry-run").Value.Get().(bool) {
-		context.Progress().Printf("\nNot creating snapshot, as dry run was requested.\n")
+		progress.Printf("\nNot creating snapshot, as dry run was requested.\n")
 	} else {
-		// Create <destination> snapshot
-		destination := deb.NewSnapshotFromPackageList(args[2], []*deb.Snapshot{snapshot, source}, packageList,
-			fmt.Sprintf("Pulled into '%s' with '%s' as source, pull request was: '%s'", snapshot.Name, source.Name, strings.Join(args[3:], " ")))
+		// Create <destination> snapshot, recording <name> and all contributing sources as parents
+		parents := append([]*deb.Snapshot{snapshot}, sources...)
+		destination := deb.NewSnapshotFromPackageList(args[1], parents, packageList,
+			fmt.Sprintf("Pulled into '%s' with '%s' as source, pull request was: '%s'", snapshot.Name, strings.Join(sourceNames, ", "), strings.Join(args[2:], " ")))
 
 		err = context.CollectionFactory().SnapshotCollection().Add(destination)
 		if err != nil {
 			return fmt.Errorf("unable to create snapshot: %s", err)
 		}
 
-		context.Progress().Printf("\nSnapshot %s successfully created.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n", destination.Name, destination.Name)
+		progress.Printf("\nSnapshot %s successfully created.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n", destination.Name, destination.Name)
 	}
 	return err
 }
@@ -177,18 +485,46 @@ func aptlySnapshotPull(cmd *commander.Command, args []string) error {
 func makeCmdSnapshotPull() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlySnapshotPull,
-		UsageLine: "pull <name> <source> <destination> <package-name> ...",
+		UsageLine: "pull <name> <destination> <package-name> ...",
 		Short:     "pull packages from another snapshot",
 		Long: `
 Command pull pulls new packages along with its' dependencies to snapshot <name>
-from snapshot <source>. Pull can upgrade package version in <name> with
-versions from <source> following dependencies. New snapshot <destination>
-is created as a result of this process. Packages could be specified simply
-as 'package-name' or as dependency 'package-name (>= version)'.
+from one or more -source snapshots. Pull can upgrade package version in <name>
+with versions from the sources following dependencies. New snapshot
+<destination> is created as a result of this process. Packages could be
+specified simply as 'package-name' or as dependency 'package-name (>= version)'.
+
+-source may be given multiple times (or as a comma-separated list) to pull
+from several snapshots at once, e.g. to combine a security and a backports
+snapshot with the main archive. Sources are tried in the order given: a
+dependency is satisfied from the first -source that has it, falling back to
+later ones only when an earlier source doesn't carry the package.
+
+With -filter-query, each <package-name> argument is instead parsed as a full
+aptly package query (the same syntax accepted by 'mirror create --filter'),
+so selectors like 'Name (% xorg-*), Priority (standard)' can be used in
+place of listing every package name.
+
+With -filter-with-deps, the dependency closure is computed in a single pass
+over the whole source snapshot (like 'mirror create -filter-with-deps'),
+rather than resolved incrementally one missing dependency at a time. This is
+significantly faster for pulls that touch thousands of packages. Each
+requested package's closure is resolved against a single source at a time,
+in priority order; any transitively-required package that closure didn't
+cover (e.g. it only exists in a lower-priority source) is retried below
+against the remaining sources the same way as any other missing dependency.
+-filter-with-deps cannot currently be combined with -filter-query.
+
+With -output=json, a machine-readable pull plan (satisfied/unsatisfied
+dependencies, packages added/removed and the resulting destination refs, per
+architecture) is printed instead of the human-readable colored log, and with
+-plan-file=<path> the same document is additionally written to a file. This
+is meant for diffing proposed pulls between runs and gating them in CI,
+especially combined with -dry-run.
 
 Example:
 
-    $ aptly snapshot pull wheezy-main wheezy-backports wheezy-new-xorg xorg-server-server
+    $ aptly snapshot pull -source=wheezy-backports wheezy-main wheezy-new-xorg xorg-server-server
 `,
 		Flag: *flag.NewFlagSet("aptly-snapshot-pull", flag.ExitOnError),
 	}
@@ -197,6 +533,11 @@ Example:
 	cmd.Flag.Bool("no-deps", false, "don't process dependencies, just pull listed packages")
 	cmd.Flag.Bool("no-remove", false, "don't remove other package versions when pulling package")
 	cmd.Flag.Bool("all-matches", false, "pull all the packages that satisfy the requirements")
+	cmd.Flag.Bool("filter-query", false, "treat each <package-name> argument as an aptly package query instead of a dependency string")
+	cmd.Flag.Bool("filter-with-deps", false, "resolve the dependency closure over the source snapshot in one pass instead of incrementally")
+	cmd.Flag.Var(&snapshotSourceList{}, "source", "snapshot to pull from, can be given multiple times (or as a comma-separated list); earlier sources take priority over later ones")
+	cmd.Flag.String("output", "", "output format for the pull plan, \"json\" prints a machine-readable plan instead of the human-readable log")
+	cmd.Flag.String("plan-file", "", "write a machine-readable JSON pull plan to the given file")
 
 	return cmd
 }
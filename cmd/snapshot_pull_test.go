@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/smira/aptly/deb"
+)
+
+func TestSnapshotSourceList(t *testing.T) {
+	var sources snapshotSourceList
+
+	if err := sources.Set("wheezy-security"); err != nil {
+		t.Fatalf("Set() returned error: %s", err)
+	}
+	if err := sources.Set("wheezy-backports, wheezy-extra"); err != nil {
+		t.Fatalf("Set() returned error: %s", err)
+	}
+	if err := sources.Set(""); err != nil {
+		t.Fatalf("Set() returned error: %s", err)
+	}
+
+	expected := []string{"wheezy-security", "wheezy-backports", "wheezy-extra"}
+	if !reflect.DeepEqual(sources.names, expected) {
+		t.Fatalf("unexpected source order: got %v, want %v (priority ordering must be preserved across "+
+			"repeated/comma-separated -source flags, as it drives which snapshot is consulted first)", sources.names, expected)
+	}
+
+	if sources.String() != "wheezy-security,wheezy-backports,wheezy-extra" {
+		t.Fatalf("unexpected String(): %q", sources.String())
+	}
+}
+
+func TestValidatePullModes(t *testing.T) {
+	if err := validatePullModes(false, false); err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if err := validatePullModes(true, false); err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if err := validatePullModes(false, true); err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if err := validatePullModes(true, true); err == nil {
+		t.Fatalf("expected -filter-query + -filter-with-deps to be rejected")
+	}
+}
+
+func TestPullSearch(t *testing.T) {
+	highPriority := deb.NewPackageList()
+	lowPriority := deb.NewPackageList()
+
+	onlyInLow := &deb.Package{Name: "libfoo", Version: "2.0", Architecture: "amd64"}
+	lowPriority.Add(onlyInLow)
+
+	inBoth := &deb.Package{Name: "libbar", Version: "1.0", Architecture: "amd64"}
+	highPriority.Add(inBoth)
+	lowPriority.Add(&deb.Package{Name: "libbar", Version: "0.9", Architecture: "amd64"})
+
+	highPriority.PrepareIndex()
+	lowPriority.PrepareIndex()
+
+	sourcePackageLists := []*deb.PackageList{highPriority, lowPriority}
+
+	// Only the lower-priority source carries libfoo, so pullSearch must fall back to it.
+	results := pullSearch(sourcePackageLists, deb.Dependency{Pkg: "libfoo", Architecture: "amd64"}, false)
+	if len(results) != 1 || results[0] != onlyInLow {
+		t.Fatalf("expected pullSearch to fall back to the lower-priority source for libfoo, got %v", results)
+	}
+
+	// libbar exists in both; the higher-priority source must win and the lower one must not be consulted.
+	results = pullSearch(sourcePackageLists, deb.Dependency{Pkg: "libbar", Architecture: "amd64"}, false)
+	if len(results) != 1 || results[0] != inBoth {
+		t.Fatalf("expected pullSearch to prefer the higher-priority source for libbar, got %v", results)
+	}
+
+	if results := pullSearch(sourcePackageLists, deb.Dependency{Pkg: "libbaz", Architecture: "amd64"}, false); results != nil {
+		t.Fatalf("expected no match for libbaz in any source, got %v", results)
+	}
+}
+
+func TestPullProcessMatches(t *testing.T) {
+	packageList := deb.NewPackageList()
+	oldVersion := &deb.Package{Name: "libfoo", Version: "1.0", Architecture: "amd64"}
+	packageList.Add(oldVersion)
+	packageList.PrepareIndex()
+
+	newVersion := &deb.Package{Name: "libfoo", Version: "2.0", Architecture: "amd64"}
+	plan := &pullPlanArch{Architecture: "amd64"}
+
+	// noDeps=true keeps this test to plan bookkeeping, without exercising dependency discovery
+	// (which needs the live package-level context that isn't set up in this test binary).
+	dependencies, err := pullProcessMatches([]*deb.Package{newVersion}, packageList, nil, "amd64",
+		false, false, true, plan, pullSilentProgress{})
+	if err != nil {
+		t.Fatalf("pullProcessMatches returned error: %s", err)
+	}
+	if len(dependencies) != 0 {
+		t.Fatalf("expected no dependencies to be discovered with noDeps=true, got %v", dependencies)
+	}
+
+	if !reflect.DeepEqual(plan.Removed, []string{oldVersion.String()}) {
+		t.Fatalf("expected old version to be recorded as removed, got %v", plan.Removed)
+	}
+	if !reflect.DeepEqual(plan.Added, []string{newVersion.String()}) {
+		t.Fatalf("expected new version to be recorded as added, got %v", plan.Added)
+	}
+	if packageList.Len() != 1 {
+		t.Fatalf("expected packageList to contain exactly the new version, got %d packages", packageList.Len())
+	}
+}